@@ -1,53 +1,134 @@
 package consumer
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Jaskaranbir/go-kafkaproxy/pkg/proxyerror"
 
 	"github.com/Shopify/sarama"
-	cluster "github.com/bsm/sarama-cluster"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// defaultMetadataHeartbeatInterval is how often the healthiness monitor
+// refreshes broker metadata when Config.HealthinessTimeout is set but no
+// finer-grained interval is implied by it.
+const defaultMetadataHeartbeatInterval = 10 * time.Second
+
+// readinessPollInterval is how often WaitUntilReady re-checks committed
+// offsets against the snapshotted high-water marks.
+const readinessPollInterval = 1 * time.Second
+
+// errorsChanBufferSize bounds Errors() so a slow or absent reader can't block
+// the consumer's internals; once full, further errors are dropped.
+const errorsChanBufferSize = 16
+
 // Adapter is the Kafka-Consumer interface
 type Adapter interface {
 	Close() error
-	CommitOffsets() error
+	Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error
 	Errors() <-chan error
-	HighWaterMarks() map[string]map[int32]int64
-	MarkOffset(msg *sarama.ConsumerMessage, metadata string)
-	MarkOffsets(s *cluster.OffsetStash)
-	MarkPartitionOffset(topic string, partition int32, offset int64, metadata string)
-	Messages() <-chan *sarama.ConsumerMessage
-	Notifications() <-chan *cluster.Notification
-	Partitions() <-chan cluster.PartitionConsumer
-	ResetOffset(msg *sarama.ConsumerMessage, metadata string)
-	ResetOffsets(s *cluster.OffsetStash)
-	ResetPartitionOffset(topic string, partition int32, offset int64, metadata string)
-	Subscriptions() map[string][]int32
+	Pause(partitions map[string][]int32)
+	PauseAll()
+	Resume(partitions map[string][]int32)
+	ResumeAll()
 }
 
+// MsgHandler processes a single Kafka message. ctx is derived from the
+// context passed to New/NewWithContext and is cancelled when the Consumer
+// is closed.
+type MsgHandler func(ctx context.Context, msg *sarama.ConsumerMessage, c *Consumer)
+
 // Config wraps configuration for consumer
 type Config struct {
 	ConsumerGroup string
 	ErrHandler    func(*error)
 	KafkaBrokers  []string
-	MsgHandler    func(*sarama.ConsumerMessage, *Consumer)
-	NtfnHandler   func(*cluster.Notification)
+	MsgHandler    MsgHandler
+	// Setup and Cleanup are invoked at the start/end of each rebalance
+	// (see sarama.ConsumerGroupHandler). Either may be nil.
+	Setup   func(sarama.ConsumerGroupSession) error
+	Cleanup func(sarama.ConsumerGroupSession) error
+	// HandleSignals opts into the Consumer closing itself on SIGINT/SIGTERM/
+	// SIGQUIT. It defaults to off, since a library embedded in a larger
+	// binary shouldn't hijack process-wide signal handling.
+	HandleSignals bool
+	// LivenessInterval is the maximum allowed gap since the last delivered
+	// message before EnableLivenessChannel reports false. Defaults to 30s.
+	LivenessInterval time.Duration
+	// HealthinessTimeout is the maximum allowed time since the last
+	// successful broker metadata refresh before EnableHealthinessChannel
+	// reports false. Leave zero to disable broker-connectivity tracking.
+	HealthinessTimeout time.Duration
+	// Parallelism, if > 0, routes claimed messages through a pool of this
+	// many workers, keyed by partition % Parallelism, so independent
+	// partitions can be handled concurrently instead of one goroutine per
+	// partition. Leave zero to dispatch directly from each partition's
+	// ConsumeClaim goroutine (the default).
+	Parallelism int
+	// DeadlockCheckInterval, if > 0 (and Parallelism > 0), enables a
+	// watchdog that checks every tick whether any partition's worker has a
+	// message checked out with no progress since the last tick. On
+	// detection it logs, reports the error via ErrHandler, and forces a
+	// rebalance by closing and rejoining the consumer group.
+	DeadlockCheckInterval time.Duration
+	// MetricsRegistry receives consume latency, handler duration, message
+	// size, lag, rebalance-count, and shutdown-duration instrumentation.
+	// Leave nil to disable (a no-op registry is used internally).
+	MetricsRegistry MetricsRegistry
+	// TracerProvider, if set, is used to extract a W3C traceparent from
+	// each message's headers and start a child span around MsgHandler.
+	TracerProvider trace.TracerProvider
+	// Logger is a sarama-compatible logger (see sarama.StdLogger) used for
+	// everything logged while EnableLogging is on. Defaults to the standard
+	// library's "log" package.
+	Logger sarama.StdLogger
 	// Allow overwriting default sarama-config
-	SaramaConfig *cluster.Config
+	SaramaConfig *sarama.Config
 	Topics       []string
 }
 
-// Consumer wraps sarama-cluster's consumer
+// Consumer wraps sarama's native ConsumerGroup
 type Consumer struct {
+	consumerMu       sync.RWMutex
 	consumer         Adapter
+	consumerGen      int64
+	client           sarama.Client
+	brokers          []string
+	consumerGroup    string
+	saramaConfig     *sarama.Config
+	topics           []string
+	errHandler       func(*error)
+	errorsChan       chan error
+	logger           sarama.StdLogger
 	isClosed         bool
 	isLoggingEnabled bool
+
+	metrics        MetricsRegistry
+	tracerProvider trace.TracerProvider
+
+	pool *workerPool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sessionMu sync.RWMutex
+	session   sarama.ConsumerGroupSession
+
+	livenessInterval   time.Duration
+	healthinessTimeout time.Duration
+
+	progressMu    sync.RWMutex
+	lastMessageAt time.Time
+
+	metadataMu     sync.RWMutex
+	lastMetadataOK time.Time
 }
 
 // To facilitate testing. This var gets overwritten by custon
@@ -55,51 +136,106 @@ type Consumer struct {
 // We don't pass the init function as argument or
 // via dependency-injection because the purpose of
 // this library is to highly abstract the kafka configs
-var initFunc func([]string, string, []string, *cluster.Config) (*cluster.Consumer, error)
+var initFunc func([]string, string, *sarama.Config) (sarama.ConsumerGroup, error)
+
+// To facilitate testing. Overwritten by tests that don't want to dial real
+// brokers for metadata/healthiness tracking.
+var newClientFunc func([]string, *sarama.Config) (sarama.Client, error)
 
 func init() {
-	initFunc = cluster.NewConsumer
+	initFunc = func(brokers []string, group string, config *sarama.Config) (sarama.ConsumerGroup, error) {
+		return sarama.NewConsumerGroup(brokers, group, config)
+	}
+	newClientFunc = sarama.NewClient
 }
 
 // New returns a configured Sarama Kafka-Consumer instance
 func New(initConfig *Config) (*Consumer, error) {
+	return NewWithContext(context.Background(), initConfig)
+}
+
+// NewWithContext returns a configured Sarama Kafka-Consumer instance whose
+// lifecycle is bound to ctx: cancelling ctx stops the consume loop and all
+// handler goroutines, same as calling Close.
+func NewWithContext(ctx context.Context, initConfig *Config) (*Consumer, error) {
 	if initConfig.KafkaBrokers == nil || len(initConfig.KafkaBrokers) == 0 {
 		errorLogMsg := proxyerror.BrokersNotSetError("No Kafka Brokers set.")
 		return nil, errorLogMsg
 	}
 
-	var config *cluster.Config
+	var config *sarama.Config
 	if initConfig.SaramaConfig != nil {
 		config = initConfig.SaramaConfig
 	} else {
-		config = cluster.NewConfig()
+		config = sarama.NewConfig()
 		config.Consumer.Offsets.Initial = sarama.OffsetNewest
 		config.Consumer.MaxProcessingTime = 10 * time.Second
 		config.Consumer.Return.Errors = true
-		config.Group.Return.Notifications = true
 	}
 
-	consumer, err := initFunc(initConfig.KafkaBrokers, initConfig.ConsumerGroup, initConfig.Topics, config)
+	consumer, err := initFunc(initConfig.KafkaBrokers, initConfig.ConsumerGroup, config)
 
 	if err != nil {
 		errorLogMsg := proxyerror.ConnectionError("Failed to join consumer group: ", initConfig.ConsumerGroup, err.Error())
 		return nil, errorLogMsg
 	}
 
+	livenessInterval := initConfig.LivenessInterval
+	if livenessInterval <= 0 {
+		livenessInterval = 30 * time.Second
+	}
+
+	var metrics MetricsRegistry = noopMetricsRegistry{}
+	if initConfig.MetricsRegistry != nil {
+		metrics = initConfig.MetricsRegistry
+	}
+
+	logger := initConfig.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	consumerCtx, cancel := context.WithCancel(ctx)
 	proxyConsumer := Consumer{
-		consumer:         consumer,
-		isClosed:         false,
-		isLoggingEnabled: false,
+		consumer:           consumer,
+		brokers:            initConfig.KafkaBrokers,
+		consumerGroup:      initConfig.ConsumerGroup,
+		saramaConfig:       config,
+		topics:             initConfig.Topics,
+		errHandler:         initConfig.ErrHandler,
+		errorsChan:         make(chan error, errorsChanBufferSize),
+		logger:             logger,
+		isClosed:           false,
+		isLoggingEnabled:   false,
+		metrics:            metrics,
+		tracerProvider:     initConfig.TracerProvider,
+		ctx:                consumerCtx,
+		cancel:             cancel,
+		livenessInterval:   livenessInterval,
+		healthinessTimeout: initConfig.HealthinessTimeout,
 	}
 
 	// Don't run these functions when mocking consumer,
 	// where initial consumer is nil.
 	// This initialization is controlled by mock consumer.
 	if consumer != nil {
-		proxyConsumer.handleKeyInterrupt()
-		proxyConsumer.handleErrors(initConfig.ErrHandler)
-		proxyConsumer.handleMessages(initConfig.MsgHandler)
-		proxyConsumer.handleNotifications(initConfig.NtfnHandler)
+		if initConfig.HandleSignals {
+			proxyConsumer.handleKeyInterrupt()
+		}
+		proxyConsumer.handleErrors(consumerCtx)
+		proxyConsumer.handleMessages(consumerCtx, initConfig)
+
+		if initConfig.HealthinessTimeout > 0 {
+			client, err := newClientFunc(initConfig.KafkaBrokers, config)
+			if err != nil {
+				if proxyConsumer.isLoggingEnabled {
+					proxyConsumer.logger.Println("Failed to open client for healthiness tracking:", err)
+				}
+			} else {
+				proxyConsumer.client = client
+				go proxyConsumer.runMetadataHeartbeat(consumerCtx)
+			}
+		}
 	}
 	// log.Println("Consumer waiting for messages.")
 	return &proxyConsumer, nil
@@ -117,9 +253,347 @@ func (c *Consumer) IsClosed() bool {
 
 // Get returns the original Sarama Kafka consumer
 func (c *Consumer) Get() Adapter {
+	c.consumerMu.RLock()
+	defer c.consumerMu.RUnlock()
 	return c.consumer
 }
 
+// consumerSnapshot returns the current adapter together with its
+// generation, both read under the same lock, so a caller can later tell
+// whether forceRejoin swapped the adapter out from under it while it was
+// blocked using the snapshotted one (see handleMessages).
+func (c *Consumer) consumerSnapshot() (Adapter, int64) {
+	c.consumerMu.RLock()
+	defer c.consumerMu.RUnlock()
+	return c.consumer, c.consumerGen
+}
+
+// isConsumeErrorFatal reports whether a non-nil error from Consume(), called
+// against the adapter at generation, should end the consume loop.
+// forceRejoin bumps the generation before closing the old adapter, and that
+// Close() can itself surface through Consume's error return (e.g. a failed
+// Cleanup or final offset commit) — so if the generation has since moved on,
+// this error is expected fallout of the deliberate swap, not a genuine
+// failure, and the loop should pick up the new adapter instead of ending.
+func (c *Consumer) isConsumeErrorFatal(err error, generation int64) bool {
+	if err == nil {
+		return false
+	}
+	_, currentGeneration := c.consumerSnapshot()
+	return currentGeneration == generation
+}
+
+// Errors returns a channel of classified errors encountered over the
+// consumer's lifetime: transient broker errors (proxyerror.TransientError),
+// forced-rebalance errors (proxyerror.RebalanceError), and unrecoverable
+// failures (proxyerror.FatalError). It's first-class API alongside
+// Config.ErrHandler, not a replacement for it. ErrHandler receives every
+// error; the channel buffers up to errorsChanBufferSize of them and drops
+// further errors while it is full.
+func (c *Consumer) Errors() <-chan error {
+	return c.errorsChan
+}
+
+// emitError is the single place errors reach the outside world: it logs
+// (when enabled), pushes onto Errors() without blocking, and invokes
+// ErrHandler.
+func (c *Consumer) emitError(err error) {
+	if c.isLoggingEnabled {
+		c.logger.Println(err)
+	}
+
+	select {
+	case c.errorsChan <- err:
+	default:
+		// Errors() is unbuffered past errorsChanBufferSize or has no
+		// reader; drop rather than block the consumer's internals.
+	}
+
+	if c.errHandler != nil {
+		c.errHandler(&err)
+	}
+}
+
+// forceRejoin closes the current consumer-group adapter and joins a fresh
+// one in its place, forcing a full rebalance. Used as a last resort when the
+// deadlock watchdog detects a stuck partition. Note this only gets the rest
+// of the group moving again: the worker goroutine wedged in the handler that
+// triggered the rejoin is not reclaimed (Go has no way to cancel a stuck
+// goroutine), so that worker slot — and any other partition hashed onto it —
+// stays unavailable for the lifetime of the process.
+func (c *Consumer) forceRejoin() {
+	if c.isLoggingEnabled {
+		c.logger.Println("Forcing consumer-group rejoin after deadlock detection.")
+	}
+
+	old := c.Get()
+	newConsumer, err := initFunc(c.brokers, c.consumerGroup, c.saramaConfig)
+	if err != nil {
+		c.emitError(proxyerror.FatalError{Err: proxyerror.ConnectionError("Failed to rejoin consumer group: ", err.Error())})
+		return
+	}
+
+	c.consumerMu.Lock()
+	c.consumer = newConsumer
+	c.consumerGen++
+	c.consumerMu.Unlock()
+
+	old.Close()
+	c.handleErrors(c.ctx)
+}
+
+// MarkMessage marks a message as consumed on the active consumer-group
+// session. This is a no-op if called outside of a ConsumeClaim (i.e. there's
+// no active session, such as between rebalances).
+func (c *Consumer) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	c.sessionMu.RLock()
+	session := c.session
+	c.sessionMu.RUnlock()
+
+	if session == nil {
+		return
+	}
+	session.MarkMessage(msg, metadata)
+}
+
+// claims returns the current session's assigned (topic -> partitions), or
+// nil if there's no active session.
+func (c *Consumer) claims() map[string][]int32 {
+	c.sessionMu.RLock()
+	session := c.session
+	c.sessionMu.RUnlock()
+
+	if session == nil {
+		return nil
+	}
+	return session.Claims()
+}
+
+// WaitUntilReady blocks until the consumer group has been assigned
+// partitions and, for every assigned (topic, partition), the group's
+// committed offset has caught up to the broker high-water mark as it stood
+// when WaitUntilReady was called. Partitions with no messages (HWM == 0) are
+// skipped, since they have nothing to catch up on.
+//
+// This closes the "consumer joined but silently dropped messages produced
+// during the join window" gap: reporting ready as soon as partitions are
+// assigned, without checking that earlier messages were actually consumed,
+// lets producer-side handlers ship traffic before the group is caught up.
+func (c *Consumer) WaitUntilReady(ctx context.Context) error {
+	claims, err := c.waitForAssignment(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, err := newClientFunc(c.brokers, c.saramaConfig)
+	if err != nil {
+		return proxyerror.ConnectionError("Failed to open client for readiness check: ", err.Error())
+	}
+	defer client.Close()
+
+	hwms := make(map[string]map[int32]int64, len(claims))
+	for topic, partitions := range claims {
+		hwms[topic] = make(map[int32]int64, len(partitions))
+		for _, partition := range partitions {
+			hwm, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return proxyerror.ConnectionError("Failed to fetch high-water mark for ", topic, ": ", err.Error())
+			}
+			hwms[topic][partition] = hwm
+		}
+	}
+
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		return proxyerror.ConnectionError("Failed to create cluster-admin for readiness check: ", err.Error())
+	}
+	defer admin.Close()
+
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+	for {
+		ready, err := c.partitionsCaughtUp(admin, hwms)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// waitForAssignment blocks until the consumer group has claimed at least one
+// partition.
+func (c *Consumer) waitForAssignment(ctx context.Context) (map[string][]int32, error) {
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if claims := c.claims(); len(claims) > 0 {
+			return claims, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// partitionsCaughtUp reports whether every partition in hwms has a committed
+// group offset at or above its snapshotted high-water mark.
+func (c *Consumer) partitionsCaughtUp(admin sarama.ClusterAdmin, hwms map[string]map[int32]int64) (bool, error) {
+	partitions := make(map[string][]int32, len(hwms))
+	for topic, byPartition := range hwms {
+		for partition := range byPartition {
+			partitions[topic] = append(partitions[topic], partition)
+		}
+	}
+
+	offsets, err := admin.ListConsumerGroupOffsets(c.consumerGroup, partitions)
+	if err != nil {
+		return false, proxyerror.ConnectionError("Failed to list consumer-group offsets: ", err.Error())
+	}
+
+	for topic, byPartition := range hwms {
+		for partition, hwm := range byPartition {
+			if hwm == 0 {
+				continue
+			}
+			block := offsets.GetBlock(topic, partition)
+			if block == nil || block.Offset == -1 || block.Offset < hwm {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// EnableLivenessChannel starts a monitor that reports whether the
+// consume loop is still making progress: true while a message has been
+// delivered within the last Config.LivenessInterval, false otherwise. The
+// channel receives only on state transitions, so Kubernetes-style liveness
+// probes can gate on it without busy-polling. Passing enable=false returns
+// nil; it does not stop a monitor started by an earlier call, which runs
+// until the consumer's context is done.
+func (c *Consumer) EnableLivenessChannel(enable bool) chan bool {
+	if !enable {
+		return nil
+	}
+
+	livenessChan := make(chan bool, 1)
+	go c.monitor(livenessChan, c.livenessInterval, func() time.Time {
+		c.progressMu.RLock()
+		defer c.progressMu.RUnlock()
+		return c.lastMessageAt
+	})
+	return livenessChan
+}
+
+// EnableHealthinessChannel starts a monitor that reports whether
+// the consumer's broker metadata refresh is succeeding within
+// Config.HealthinessTimeout. Unlike liveness, this reflects broker
+// connectivity rather than consume-loop progress, so it stays true even
+// during periods with no traffic. The channel receives only on state
+// transitions. Passing enable=false returns nil without stopping a monitor
+// started by an earlier call, which runs until the consumer's context is
+// done; it also returns nil if Config.HealthinessTimeout was never set.
+func (c *Consumer) EnableHealthinessChannel(enable bool) chan bool {
+	if !enable || c.healthinessTimeout <= 0 {
+		return nil
+	}
+
+	healthinessChan := make(chan bool, 1)
+	go c.monitor(healthinessChan, c.healthinessTimeout, func() time.Time {
+		c.metadataMu.RLock()
+		defer c.metadataMu.RUnlock()
+		return c.lastMetadataOK
+	})
+	return healthinessChan
+}
+
+// monitor polls lastFn every threshold/2 (or every
+// defaultMetadataHeartbeatInterval if that is not positive) and pushes a value
+// onto out whenever the "last seen within threshold" state changes,
+// including its initial value. It exits and closes out once the consumer's
+// context is done.
+func (c *Consumer) monitor(out chan bool, threshold time.Duration, lastFn func() time.Time) {
+	defer close(out)
+
+	checkInterval := threshold / 2
+	if checkInterval <= 0 {
+		checkInterval = defaultMetadataHeartbeatInterval
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	state, haveState := false, false
+	for {
+		select {
+		case <-ticker.C:
+			last := lastFn()
+			ok := !last.IsZero() && time.Since(last) < threshold
+			if !haveState || ok != state {
+				state, haveState = ok, true
+				select {
+				case out <- ok:
+				case <-c.ctx.Done():
+					return
+				}
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Consumer) markProgress() {
+	c.progressMu.Lock()
+	c.lastMessageAt = time.Now()
+	c.progressMu.Unlock()
+}
+
+// runMetadataHeartbeat periodically refreshes broker metadata via c.client
+// and records the timestamp of the last successful refresh, which backs
+// EnableHealthinessChannel.
+func (c *Consumer) runMetadataHeartbeat(ctx context.Context) {
+	interval := defaultMetadataHeartbeatInterval
+	if half := c.healthinessTimeout / 2; half > 0 && half < interval {
+		interval = half
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.client.RefreshMetadata(); err == nil {
+				c.metadataMu.Lock()
+				c.lastMetadataOK = time.Now()
+				c.metadataMu.Unlock()
+			} else if c.isLoggingEnabled {
+				c.logger.Println("Failed to refresh broker metadata:", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Consumer) setSession(session sarama.ConsumerGroupSession) {
+	c.sessionMu.Lock()
+	c.session = session
+	c.sessionMu.Unlock()
+}
+
 func (c *Consumer) handleKeyInterrupt() {
 	// Capture the Ctrl+C signal (interrupt or kill)
 	sigChan := make(chan os.Signal, 1)
@@ -130,74 +604,212 @@ func (c *Consumer) handleKeyInterrupt() {
 
 	// Elegant exit
 	go func() {
-		<-sigChan
-		log.Println("Keyboard-Interrupt signal received.")
-		closeError := <-c.Close()
-		log.Println(closeError)
+		select {
+		case <-sigChan:
+			c.logger.Println("Keyboard-Interrupt signal received.")
+			closeError := <-c.Close(context.Background())
+			c.logger.Println(closeError)
+		case <-c.ctx.Done():
+		}
 	}()
 }
 
-func (c *Consumer) handleErrors(errHandler func(*error)) {
+// handleErrors relays errors off the underlying adapter's Errors() channel,
+// classified as transient (sarama already retries most broker-level errors
+// internally, so these rarely need caller action beyond visibility).
+func (c *Consumer) handleErrors(ctx context.Context) {
 	consumer := c.Get()
 	go func() {
-		for err := range consumer.Errors() {
-			if c.isLoggingEnabled {
-				log.Fatalln("Failed to read messages from topic:", err)
-			}
-			if errHandler != nil {
-				errHandler(&err)
+		for {
+			select {
+			case err, ok := <-consumer.Errors():
+				if !ok {
+					return
+				}
+				c.emitError(proxyerror.TransientError{Err: err})
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 }
 
-func (c *Consumer) handleMessages(msgHandler func(*sarama.ConsumerMessage, *Consumer)) {
-	consumer := c.Get()
+// handleMessages drives the consumer-group's rebalance loop. Consume blocks
+// until a rebalance occurs, so it's run in a loop for the lifetime of the
+// consumer (see the sarama.ConsumerGroup example in the sarama docs). The
+// adapter is re-fetched via consumerSnapshot on every iteration so a
+// watchdog-forced rejoin (see forceRejoin) is picked up without restarting
+// this goroutine.
+func (c *Consumer) handleMessages(ctx context.Context, initConfig *Config) {
+	msgHandler := c.instrumentHandler(initConfig.MsgHandler)
+
+	var pool *workerPool
+	if initConfig.Parallelism > 0 {
+		pool = newWorkerPool(c, msgHandler, initConfig.Parallelism)
+		c.pool = pool
+	}
+
+	handler := &groupHandler{
+		consumer:   c,
+		msgHandler: msgHandler,
+		pool:       pool,
+		setup:      initConfig.Setup,
+		cleanup:    initConfig.Cleanup,
+	}
+
 	go func() {
-		for message := range consumer.Messages() {
-			if c.isLoggingEnabled {
-				log.Printf("Topic: %s\t Partition: %v\t Offset: %v\n", message.Topic, message.Partition, message.Offset)
+		if pool != nil {
+			defer pool.close()
+		}
+		for {
+			if ctx.Err() != nil {
+				return
 			}
-			msgHandler(message, c)
+
+			adapter, generation := c.consumerSnapshot()
+			err := adapter.Consume(ctx, c.topics, handler)
+			if err == nil {
+				continue
+			}
+
+			if !c.isConsumeErrorFatal(err, generation) {
+				continue
+			}
+
+			c.emitError(proxyerror.FatalError{Err: err})
+			return
 		}
 	}()
+
+	if pool != nil && initConfig.DeadlockCheckInterval > 0 {
+		go c.runDeadlockWatchdog(ctx, initConfig.DeadlockCheckInterval)
+	}
 }
 
-// Consumer-Rebalancing notifications
-func (c *Consumer) handleNotifications(ntfnHandler func(*cluster.Notification)) {
-	consumer := c.Get()
-	go func() {
-		for ntf := range consumer.Notifications() {
-			if c.isLoggingEnabled {
-				log.Printf("Rebalanced: %+v\n", ntf)
+// runDeadlockWatchdog periodically checks the worker pool for partitions
+// stuck mid-message and, on detection, reports the error and forces a
+// rebalance.
+func (c *Consumer) runDeadlockWatchdog(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			stuck := c.pool.checkDeadlocks(interval)
+			if len(stuck) == 0 {
+				continue
 			}
-			if ntfnHandler != nil {
-				ntfnHandler(ntf)
+
+			deadlockErr := proxyerror.RebalanceError{
+				Err: proxyerror.ConnectionError("Possible deadlock on partitions: ", strings.Join(stuck, ", ")),
 			}
+			c.emitError(deadlockErr)
+			c.forceRejoin()
+		case <-ctx.Done():
+			return
 		}
-	}()
+	}
 }
 
-// Close attempts to close the consumer,
-// and returns any occurring errors over channel
-func (c *Consumer) Close() chan error {
+// groupHandler adapts Config's Setup/Cleanup/MsgHandler callbacks to
+// sarama.ConsumerGroupHandler.
+type groupHandler struct {
+	consumer   *Consumer
+	msgHandler MsgHandler
+	pool       *workerPool
+	setup      func(sarama.ConsumerGroupSession) error
+	cleanup    func(sarama.ConsumerGroupSession) error
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim
+func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.consumer.setSession(session)
+	h.consumer.metrics.IncCounter("kafkaproxy_rebalances_total", nil)
+	if h.setup != nil {
+		return h.setup(session)
+	}
+	return nil
+}
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines
+// have exited
+func (h *groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	h.consumer.setSession(nil)
+	if h.cleanup != nil {
+		return h.cleanup(session)
+	}
+	return nil
+}
+
+// ConsumeClaim processes messages for a single claimed partition. Per the
+// sarama.ConsumerGroupHandler contract, it must run until the claim's
+// Messages channel is closed (on rebalance) or the session's context is done.
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if h.consumer.isLoggingEnabled {
+				h.consumer.logger.Printf("Topic: %s\t Partition: %v\t Offset: %v\n", message.Topic, message.Partition, message.Offset)
+			}
+			h.consumer.markProgress()
+			h.consumer.recordMessageMetrics(claim, message)
+			if h.pool != nil {
+				h.pool.dispatch(session, message)
+			} else if h.msgHandler != nil {
+				h.msgHandler(session.Context(), message, h.consumer)
+			}
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// Close attempts to close the consumer, cancelling its context and stopping
+// all handler goroutines. ctx bounds how long Close waits for the underlying
+// sarama.ConsumerGroup to shut down; any occurring errors are returned over
+// the returned channel.
+func (c *Consumer) Close(ctx context.Context) chan error {
 	if c.IsClosed() {
 		return nil
 	}
 
 	closeErrorChan := make(chan error, 1)
 	go func() {
-		err := c.Get().Close()
-		if err != nil {
+		closeStart := time.Now()
+		defer func() {
+			c.metrics.ObserveHistogram("kafkaproxy_shutdown_duration_seconds", time.Since(closeStart).Seconds(), nil)
+		}()
+
+		c.isClosed = true
+		c.cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			err := c.Get().Close()
+			if c.client != nil {
+				if clientErr := c.client.Close(); clientErr != nil && err == nil {
+					err = clientErr
+				}
+			}
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				c.emitError(proxyerror.FatalError{Err: err})
+				closeErrorChan <- err
+			}
 			if c.isLoggingEnabled {
-				log.Fatal("Error closing consumer.", err)
+				c.logger.Println("Consumer closed.")
 			}
-			closeErrorChan <- err
+		case <-ctx.Done():
+			closeErrorChan <- ctx.Err()
 		}
-		if c.isLoggingEnabled {
-			log.Println("Consumer closed.")
-		}
-		c.isClosed = true
 	}()
 
 	return closeErrorChan
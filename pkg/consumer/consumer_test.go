@@ -0,0 +1,105 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClusterAdmin embeds sarama.ClusterAdmin (nil) so it satisfies the
+// interface while only overriding the one method partitionsCaughtUp uses.
+type fakeClusterAdmin struct {
+	sarama.ClusterAdmin
+	offsets *sarama.OffsetFetchResponse
+	err     error
+}
+
+func (a fakeClusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error) {
+	return a.offsets, a.err
+}
+
+func offsetFetchResponse(blocks map[string]map[int32]int64) *sarama.OffsetFetchResponse {
+	resp := &sarama.OffsetFetchResponse{Blocks: make(map[string]map[int32]*sarama.OffsetFetchResponseBlock)}
+	for topic, byPartition := range blocks {
+		resp.Blocks[topic] = make(map[int32]*sarama.OffsetFetchResponseBlock)
+		for partition, offset := range byPartition {
+			resp.Blocks[topic][partition] = &sarama.OffsetFetchResponseBlock{Offset: offset}
+		}
+	}
+	return resp
+}
+
+func TestPartitionsCaughtUp(t *testing.T) {
+	c := &Consumer{consumerGroup: "test-group"}
+	hwms := map[string]map[int32]int64{"topic": {0: 10, 1: 0}}
+
+	t.Run("not caught up", func(t *testing.T) {
+		admin := fakeClusterAdmin{offsets: offsetFetchResponse(map[string]map[int32]int64{"topic": {0: 5}})}
+		ready, err := c.partitionsCaughtUp(admin, hwms)
+		assert.NoError(t, err)
+		assert.False(t, ready)
+	})
+
+	t.Run("caught up, zero-HWM partition skipped", func(t *testing.T) {
+		admin := fakeClusterAdmin{offsets: offsetFetchResponse(map[string]map[int32]int64{"topic": {0: 10}})}
+		ready, err := c.partitionsCaughtUp(admin, hwms)
+		assert.NoError(t, err)
+		assert.True(t, ready)
+	})
+
+	t.Run("admin error propagates", func(t *testing.T) {
+		admin := fakeClusterAdmin{err: errors.New("boom")}
+		ready, err := c.partitionsCaughtUp(admin, hwms)
+		assert.Error(t, err)
+		assert.False(t, ready)
+	})
+}
+
+func TestWaitForAssignment(t *testing.T) {
+	t.Run("returns once claims are assigned", func(t *testing.T) {
+		c := &Consumer{}
+		c.setSession(fakeSession{ctx: context.Background(), claims: map[string][]int32{"topic": {0}}})
+
+		claims, err := c.waitForAssignment(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]int32{"topic": {0}}, claims)
+	})
+
+	t.Run("returns context error if cancelled before assignment", func(t *testing.T) {
+		c := &Consumer{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.waitForAssignment(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestIsConsumeErrorFatal(t *testing.T) {
+	t.Run("nil error is never fatal", func(t *testing.T) {
+		c := &Consumer{}
+		assert.False(t, c.isConsumeErrorFatal(nil, 0))
+	})
+
+	t.Run("error is fatal when no rejoin happened in the meantime", func(t *testing.T) {
+		c := &Consumer{}
+		_, generation := c.consumerSnapshot()
+		assert.True(t, c.isConsumeErrorFatal(errors.New("boom"), generation))
+	})
+
+	t.Run("error is not fatal when forceRejoin swapped the adapter in the meantime", func(t *testing.T) {
+		c := &Consumer{}
+		_, generation := c.consumerSnapshot()
+
+		// Simulate forceRejoin swapping in a new adapter while Consume (on
+		// the old one) was still in flight.
+		c.consumerMu.Lock()
+		c.consumerGen++
+		c.consumerMu.Unlock()
+
+		assert.False(t, c.isConsumeErrorFatal(errors.New("boom"), generation))
+	})
+}
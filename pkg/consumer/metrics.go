@@ -0,0 +1,96 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies spans started by this package.
+const tracerName = "github.com/Jaskaranbir/go-kafkaproxy/pkg/consumer"
+
+// MetricsRegistry is a minimal, provider-agnostic metrics sink. Implement it
+// to wire the consumer's instrumentation into Prometheus, statsd, or
+// whatever's already in use; a nil Config.MetricsRegistry is replaced with a
+// no-op implementation, so existing users see zero behavior change.
+type MetricsRegistry interface {
+	IncCounter(name string, tags map[string]string)
+	ObserveHistogram(name string, value float64, tags map[string]string)
+	SetGauge(name string, value float64, tags map[string]string)
+}
+
+// noopMetricsRegistry discards every call. It's the default when
+// Config.MetricsRegistry is nil.
+type noopMetricsRegistry struct{}
+
+func (noopMetricsRegistry) IncCounter(string, map[string]string)                {}
+func (noopMetricsRegistry) ObserveHistogram(string, float64, map[string]string) {}
+func (noopMetricsRegistry) SetGauge(string, float64, map[string]string)         {}
+
+// saramaHeaderCarrier adapts sarama's message headers to
+// propagation.TextMapCarrier, so a W3C traceparent header can be extracted
+// with the standard otel propagator.
+type saramaHeaderCarrier []*sarama.RecordHeader
+
+func (c saramaHeaderCarrier) Get(key string) string {
+	for _, header := range c {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c saramaHeaderCarrier) Set(string, string) {
+	// Extraction-only: the consumer never injects headers back onto a
+	// sarama.ConsumerMessage.
+}
+
+func (c saramaHeaderCarrier) Keys() []string {
+	keys := make([]string, len(c))
+	for i, header := range c {
+		keys[i] = string(header.Key)
+	}
+	return keys
+}
+
+// recordMessageMetrics reports per-message consume latency, payload size,
+// and consumer lag as soon as a message is received off the claim, ahead of
+// handler dispatch.
+func (c *Consumer) recordMessageMetrics(claim sarama.ConsumerGroupClaim, message *sarama.ConsumerMessage) {
+	tags := map[string]string{"topic": message.Topic}
+	c.metrics.ObserveHistogram("kafkaproxy_consume_latency_seconds", time.Since(message.Timestamp).Seconds(), tags)
+	c.metrics.ObserveHistogram("kafkaproxy_message_bytes", float64(len(message.Value)), tags)
+	c.metrics.SetGauge("kafkaproxy_consumer_lag", float64(claim.HighWaterMarkOffset()-message.Offset-1), tags)
+}
+
+// instrumentHandler wraps handler with handler-duration metrics and, when a
+// TracerProvider is configured, a child span extracted from the message's
+// W3C traceparent header.
+func (c *Consumer) instrumentHandler(handler MsgHandler) MsgHandler {
+	if handler == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, msg *sarama.ConsumerMessage, con *Consumer) {
+		ctx, span := c.startSpan(ctx, msg)
+		defer span.End()
+
+		start := time.Now()
+		handler(ctx, msg, con)
+		c.metrics.ObserveHistogram("kafkaproxy_handler_duration_seconds", time.Since(start).Seconds(), map[string]string{"topic": msg.Topic})
+	}
+}
+
+func (c *Consumer) startSpan(ctx context.Context, msg *sarama.ConsumerMessage) (context.Context, trace.Span) {
+	if c.tracerProvider == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	ctx = propagation.TraceContext{}.Extract(ctx, saramaHeaderCarrier(msg.Headers))
+	tracer := c.tracerProvider.Tracer(tracerName)
+	return tracer.Start(ctx, "consumer.MsgHandler", trace.WithSpanKind(trace.SpanKindConsumer))
+}
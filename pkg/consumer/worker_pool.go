@@ -0,0 +1,140 @@
+package consumer
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// consumeJob is a single claimed message dispatched to a worker.
+type consumeJob struct {
+	session sarama.ConsumerGroupSession
+	message *sarama.ConsumerMessage
+}
+
+// partitionState tracks a single partition's worker-pool progress, for
+// deadlock detection.
+type partitionState struct {
+	mu sync.Mutex
+	// inFlight and jobStartedAt are stamped together when a job is handed
+	// to the worker, so checkDeadlocks measures how long the *current* job
+	// has been running rather than how long the partition was idle before
+	// it.
+	inFlight     bool
+	jobStartedAt time.Time
+	lastProgress time.Time
+	// deadlockReported is set once checkDeadlocks flags this partition, so
+	// a handler that never returns is reported (and forceRejoin triggered)
+	// once rather than on every subsequent watchdog tick.
+	deadlockReported bool
+}
+
+// workerPool dispatches claimed messages into a fixed number of workers,
+// keyed by partition % len(workers), so independent partitions are handled
+// concurrently while each partition's messages still land on a single
+// worker and are processed in order.
+type workerPool struct {
+	consumer   *Consumer
+	msgHandler MsgHandler
+	workers    []chan consumeJob
+
+	statesMu sync.Mutex
+	states   map[string]map[int32]*partitionState
+}
+
+func newWorkerPool(consumer *Consumer, msgHandler MsgHandler, parallelism int) *workerPool {
+	pool := &workerPool{
+		consumer:   consumer,
+		msgHandler: msgHandler,
+		workers:    make([]chan consumeJob, parallelism),
+		states:     make(map[string]map[int32]*partitionState),
+	}
+	for i := range pool.workers {
+		pool.workers[i] = make(chan consumeJob)
+		go pool.runWorker(pool.workers[i])
+	}
+	return pool
+}
+
+func (p *workerPool) runWorker(jobs chan consumeJob) {
+	for job := range jobs {
+		state := p.stateFor(job.message.Topic, job.message.Partition)
+
+		state.mu.Lock()
+		state.inFlight = true
+		state.jobStartedAt = time.Now()
+		state.mu.Unlock()
+
+		if p.msgHandler != nil {
+			p.msgHandler(job.session.Context(), job.message, p.consumer)
+		}
+
+		state.mu.Lock()
+		state.inFlight = false
+		state.lastProgress = time.Now()
+		state.deadlockReported = false
+		state.mu.Unlock()
+	}
+}
+
+func (p *workerPool) stateFor(topic string, partition int32) *partitionState {
+	p.statesMu.Lock()
+	defer p.statesMu.Unlock()
+
+	byPartition, ok := p.states[topic]
+	if !ok {
+		byPartition = make(map[int32]*partitionState)
+		p.states[topic] = byPartition
+	}
+
+	state, ok := byPartition[partition]
+	if !ok {
+		state = &partitionState{lastProgress: time.Now()}
+		byPartition[partition] = state
+	}
+	return state
+}
+
+// dispatch enqueues message onto the worker responsible for its partition,
+// blocking until the worker accepts it or session's context is cancelled.
+func (p *workerPool) dispatch(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) {
+	worker := p.workers[int(message.Partition)%len(p.workers)]
+	select {
+	case worker <- consumeJob{session: session, message: message}:
+	case <-session.Context().Done():
+	}
+}
+
+// close stops every worker goroutine. Workers finish any in-flight job
+// before exiting.
+func (p *workerPool) close() {
+	for _, worker := range p.workers {
+		close(worker)
+	}
+}
+
+// checkDeadlocks returns "topic/partition" for every partition whose current
+// job has been checked out to a worker for longer than threshold without
+// returning. Each stuck partition is only ever reported once (until its
+// worker picks up a further job) — the handler behind it is never coming
+// back, so re-reporting it on every subsequent tick would just force a
+// rejoin in a loop for no additional recovery.
+func (p *workerPool) checkDeadlocks(threshold time.Duration) []string {
+	p.statesMu.Lock()
+	defer p.statesMu.Unlock()
+
+	var stuck []string
+	for topic, byPartition := range p.states {
+		for partition, state := range byPartition {
+			state.mu.Lock()
+			if state.inFlight && !state.deadlockReported && time.Since(state.jobStartedAt) > threshold {
+				stuck = append(stuck, topic+"/"+strconv.Itoa(int(partition)))
+				state.deadlockReported = true
+			}
+			state.mu.Unlock()
+		}
+	}
+	return stuck
+}
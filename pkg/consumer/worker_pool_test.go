@@ -0,0 +1,85 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSession is a minimal sarama.ConsumerGroupSession backed by an in-memory
+// claims map, covering the two methods these tests rely on: Context()
+// (worker dispatch) and Claims() (readiness gate).
+type fakeSession struct {
+	ctx    context.Context
+	claims map[string][]int32
+}
+
+func (s fakeSession) Claims() map[string][]int32                                               { return s.claims }
+func (s fakeSession) MemberID() string                                                         { return "" }
+func (s fakeSession) GenerationID() int32                                                      { return 0 }
+func (s fakeSession) MarkOffset(topic string, partition int32, offset int64, metadata string)  {}
+func (s fakeSession) Commit()                                                                  {}
+func (s fakeSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {}
+func (s fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string)                 {}
+func (s fakeSession) Context() context.Context                                                 { return s.ctx }
+
+func newTestSession() sarama.ConsumerGroupSession {
+	return fakeSession{ctx: context.Background()}
+}
+
+// TestCheckDeadlocks_IgnoresIdleGapBeforeJobStart reproduces the deadlock
+// watchdog false positive: a partition that sits idle longer than the
+// threshold and then picks up a job must not be reported stuck just because
+// its last completed job predates the threshold.
+func TestCheckDeadlocks_IgnoresIdleGapBeforeJobStart(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	pool := newWorkerPool(&Consumer{}, func(ctx context.Context, msg *sarama.ConsumerMessage, c *Consumer) {
+		close(started)
+		<-finish
+	}, 1)
+	defer close(finish)
+	defer pool.close()
+
+	// Simulate a partition that has been idle for an hour.
+	state := pool.stateFor("topic", 0)
+	state.mu.Lock()
+	state.lastProgress = time.Now().Add(-time.Hour)
+	state.mu.Unlock()
+
+	session := newTestSession()
+	go pool.dispatch(session, &sarama.ConsumerMessage{Topic: "topic", Partition: 0})
+
+	<-started
+	stuck := pool.checkDeadlocks(5 * time.Second)
+	assert.Empty(t, stuck, "a freshly started job must not be flagged stuck because of a stale lastProgress")
+}
+
+// TestCheckDeadlocks_ReportsStuckJobOnce verifies a job that genuinely
+// exceeds the threshold is reported, and that it is only reported once
+// rather than on every subsequent watchdog tick.
+func TestCheckDeadlocks_ReportsStuckJobOnce(t *testing.T) {
+	started := make(chan struct{})
+	block := make(chan struct{})
+	pool := newWorkerPool(&Consumer{}, func(ctx context.Context, msg *sarama.ConsumerMessage, c *Consumer) {
+		close(started)
+		<-block // closed only when the test ends: simulates a wedged handler
+	}, 1)
+	defer close(block)
+	defer pool.close()
+
+	session := newTestSession()
+	go pool.dispatch(session, &sarama.ConsumerMessage{Topic: "topic", Partition: 0})
+
+	<-started
+	time.Sleep(20 * time.Millisecond)
+
+	stuck := pool.checkDeadlocks(10 * time.Millisecond)
+	assert.Equal(t, []string{"topic/0"}, stuck)
+
+	stuck = pool.checkDeadlocks(10 * time.Millisecond)
+	assert.Empty(t, stuck, "an already-reported deadlock must not be reported again")
+}
@@ -0,0 +1,73 @@
+// Package proxyerror defines the error types returned and surfaced by the
+// consumer package.
+package proxyerror
+
+import "fmt"
+
+// BrokersNotSetError indicates New/NewWithContext was called without any
+// Kafka brokers configured.
+type BrokersNotSetError string
+
+func (e BrokersNotSetError) Error() string {
+	return string(e)
+}
+
+// ConnectionErr wraps a failure to reach or operate against a Kafka broker.
+type ConnectionErr string
+
+func (e ConnectionErr) Error() string {
+	return string(e)
+}
+
+// ConnectionError builds a ConnectionErr by concatenating its message parts.
+func ConnectionError(parts ...string) ConnectionErr {
+	msg := ""
+	for _, part := range parts {
+		msg += part
+	}
+	return ConnectionErr(msg)
+}
+
+// TransientError wraps an error that's expected to resolve on its own, such
+// as the broker-level errors sarama already retries internally. Callers can
+// typically log and continue.
+type TransientError struct {
+	Err error
+}
+
+func (e TransientError) Error() string {
+	return fmt.Sprintf("transient error: %s", e.Err)
+}
+
+func (e TransientError) Unwrap() error {
+	return e.Err
+}
+
+// FatalError wraps an error the consumer could not recover from on its own,
+// such as a terminated consume loop or a failed rejoin. Callers should treat
+// these as requiring operator attention or a restart.
+type FatalError struct {
+	Err error
+}
+
+func (e FatalError) Error() string {
+	return fmt.Sprintf("fatal error: %s", e.Err)
+}
+
+func (e FatalError) Unwrap() error {
+	return e.Err
+}
+
+// RebalanceError wraps an error raised in the course of, or that triggers, a
+// consumer-group rebalance, such as the deadlock watchdog forcing a rejoin.
+type RebalanceError struct {
+	Err error
+}
+
+func (e RebalanceError) Error() string {
+	return fmt.Sprintf("rebalance error: %s", e.Err)
+}
+
+func (e RebalanceError) Unwrap() error {
+	return e.Err
+}